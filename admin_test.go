@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	app := setup()
+	app.DB.Create(&Star{Name: "test/name", SubmitterIP: "192.0.2.1"})
+
+	// Set up a test table of admin token settings and credentials.
+	authTests := []struct {
+		adminToken    string
+		authorization string
+		status        int
+	}{
+		{adminToken: "", authorization: "", status: http.StatusForbidden},
+		{adminToken: "", authorization: "Bearer ", status: http.StatusForbidden},
+		{adminToken: "secret", authorization: "", status: http.StatusUnauthorized},
+		{adminToken: "secret", authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{adminToken: "secret", authorization: "secret", status: http.StatusUnauthorized},
+		{adminToken: "secret", authorization: "Bearer secret", status: http.StatusOK},
+	}
+
+	// Every admin route should be protected.
+	adminRoutes := []struct {
+		method string
+		path   string
+	}{
+		{method: "GET", path: "/admin/stats/traffic"},
+		{method: "GET", path: "/admin/validate-urls"},
+		{method: "GET", path: "/admin/stars/test/name"},
+		{method: "POST", path: "/admin/reindex-search"},
+	}
+
+	for _, tt := range authTests {
+		app.Config.AdminToken = tt.adminToken
+		r := app.Routes()
+
+		for _, route := range adminRoutes {
+			// Set up a new request.
+			req, err := http.NewRequest(route.method, route.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// Test that the status code is correct. Reindexing is allowed
+			// through, but may not be available in this build.
+			status := rr.Code
+			if route.path == "/admin/reindex-search" && status == http.StatusNotImplemented {
+				status = http.StatusOK
+			}
+			if status != tt.status {
+				t.Errorf("Status code for %s %s with %q is invalid. Expected %d. Got %d instead",
+					route.method, route.path, tt.authorization, tt.status, rr.Code)
+			}
+		}
+	}
+
+	teardown(app)
+}
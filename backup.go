@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backupTimeFormat sorts lexically in time order, which rotation relies on.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// backupStar holds every stored column of a star, including those that
+// API responses leave out.
+type backupStar struct {
+	ID                 uint       `json:"id"`
+	Name               string     `json:"name"`
+	Description        string     `json:"description"`
+	URL                string     `json:"url"`
+	Read               bool       `json:"read"`
+	ReadAt             *time.Time `json:"read_at"`
+	SubmitterIP        string     `json:"submitter_ip"`
+	SubmitterUserAgent string     `json:"submitter_user_agent"`
+}
+
+// Backup writes every star to a timestamped JSON file in Config.BackupDir,
+// then removes all but the newest Config.BackupKeep backups.
+func (a *App) Backup() (string, error) {
+	var stars []backupStar
+	if err := a.DB.Table("stars").Order("id asc").Find(&stars).Error; err != nil {
+		return "", err
+	}
+	starsJSON, err := json.Marshal(stars)
+	if err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first, so a partial backup is never left behind.
+	name := "stars-" + time.Now().UTC().Format(backupTimeFormat) + ".json"
+	path := filepath.Join(a.Config.BackupDir, name)
+	if err := ioutil.WriteFile(path+".tmp", starsJSON, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", err
+	}
+
+	return path, a.rotateBackups()
+}
+
+func (a *App) rotateBackups() error {
+	if a.Config.BackupKeep <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(filepath.Join(a.Config.BackupDir, "stars-*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+
+	for len(backups) > a.Config.BackupKeep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// StartBackups runs Backup every Config.BackupInterval in the background.
+// The returned function stops the backups and waits for any in progress.
+func (a *App) StartBackups() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(a.Config.BackupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if path, err := a.Backup(); err != nil {
+					log.Printf("backup failed: %s", err)
+				} else {
+					log.Printf("backed up stars to %s", path)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
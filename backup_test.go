@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartBackups(t *testing.T) {
+	app := setup()
+
+	dir, err := ioutil.TempDir("", "starmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app.Config.BackupDir = dir
+	app.Config.BackupInterval = 10 * time.Millisecond
+	app.Config.BackupKeep = 2
+
+	// Create a star to back up.
+	testStar := Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL",
+		SubmitterIP: "192.0.2.1", SubmitterUserAgent: "test-agent/1.0"}
+	app.DB.Create(&testStar)
+
+	// Leave an old backup around that should get rotated out.
+	oldBackup := filepath.Join(dir, "stars-20000101T000000.000000000Z.json")
+	if err := ioutil.WriteFile(oldBackup, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run long enough for several backups.
+	stop := app.StartBackups()
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	// Test that only the newest backups were kept.
+	backups, err := filepath.Glob(filepath.Join(dir, "stars-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != app.Config.BackupKeep {
+		t.Fatalf("Backup count is invalid. Expected %d. Got %d instead", app.Config.BackupKeep, len(backups))
+	}
+	if _, err := os.Stat(oldBackup); !os.IsNotExist(err) {
+		t.Errorf("Old backup was not rotated out")
+	}
+
+	// Test that the backups contain the star, including fields the API hides.
+	expected := backupStar{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL",
+		SubmitterIP: "192.0.2.1", SubmitterUserAgent: "test-agent/1.0"}
+	for _, backup := range backups {
+		data, err := ioutil.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backedUpStars := []backupStar{}
+		if err := json.Unmarshal(data, &backedUpStars); err != nil {
+			t.Errorf("Backup is invalid JSON. Got: %s", data)
+		}
+		if len(backedUpStars) != 1 || backedUpStars[0] != expected {
+			t.Errorf("Backup is invalid. Expected [%+v]. Got %+v instead", expected, backedUpStars)
+		}
+	}
+
+	teardown(app)
+}
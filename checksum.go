@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ChecksumHandler returns a hash over every star, so that sync clients can
+// tell whether anything changed without fetching the whole list. Stars have
+// no update timestamps, so the hash covers all of their stored fields.
+func (a *App) ChecksumHandler(w http.ResponseWriter, r *http.Request) {
+	var stars []Star
+
+	// Select everything but the ID, in a stable order.
+	if err := a.DB.Select("name, description, url, read, read_at").Order("name asc").Find(&stars).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	// Length-prefix each field so that different stars can't hash the same.
+	hash := sha256.New()
+	for _, star := range stars {
+		readAt := ""
+		if star.ReadAt != nil {
+			readAt = star.ReadAt.UTC().Format(time.RFC3339Nano)
+		}
+		for _, field := range []string{star.Name, star.Description, star.URL, fmt.Sprint(star.Read), readAt} {
+			fmt.Fprintf(hash, "%d:%s", len(field), field)
+		}
+	}
+	checksumJSON, _ := json.Marshal(map[string]interface{}{
+		"checksum": hex.EncodeToString(hash.Sum(nil)),
+		"count":    len(stars),
+	})
+
+	// Write to HTTP response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(checksumJSON)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getChecksum(t *testing.T, app *App) string {
+	req, err := http.NewRequest("GET", "/stars/checksum", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.ChecksumHandler).ServeHTTP(rr, req)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusOK, status)
+	}
+
+	body := struct {
+		Checksum string `json:"checksum"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Checksum == "" {
+		t.Fatalf("Checksum response is invalid. Got: %s", rr.Body.String())
+	}
+	return body.Checksum
+}
+
+func TestChecksumHandler(t *testing.T) {
+	stars := []Star{
+		Star{Name: "test/name", Description: "test desc", URL: "test URL"},
+		Star{Name: "test/another_name", Description: "test desc 2", URL: "http://example.com/"},
+	}
+
+	// Create the same stars in two databases, in different orders.
+	app := setup()
+	other := setup()
+	for index := range stars {
+		app.DB.Create(&Star{Name: stars[index].Name, Description: stars[index].Description, URL: stars[index].URL})
+		star := stars[len(stars)-1-index]
+		other.DB.Create(&Star{Name: star.Name, Description: star.Description, URL: star.URL})
+	}
+
+	// Test that the checksum is stable, and the same for identical datasets.
+	checksum := getChecksum(t, app)
+	if again := getChecksum(t, app); again != checksum {
+		t.Errorf("Checksum is unstable. Got %s, then %s", checksum, again)
+	}
+	if otherChecksum := getChecksum(t, other); otherChecksum != checksum {
+		t.Errorf("Checksums for identical datasets differ. Got %s and %s", checksum, otherChecksum)
+	}
+
+	// Test that the checksum changes after a create.
+	app.DB.Create(&Star{Name: "test/new_name"})
+	if changed := getChecksum(t, app); changed == checksum {
+		t.Errorf("Checksum did not change after a create")
+	}
+
+	teardown(other)
+	teardown(app)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// recentCreates remembers creates within Config.CreateDedupWindow.
+type recentCreates struct {
+	mu    sync.Mutex
+	times map[string]time.Time
+}
+
+// seen reports whether a create with the given key succeeded within window.
+func (c *recentCreates) seen(key string, now time.Time, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop old creates so the map doesn't grow without bound.
+	for k, t := range c.times {
+		if now.Sub(t) > window {
+			delete(c.times, k)
+		}
+	}
+
+	_, ok := c.times[key]
+	return ok
+}
+
+// record notes a successful create with the given key. Duplicates found by
+// seen aren't recorded, so the window runs from the original create.
+func (c *recentCreates) record(key string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.times == nil {
+		c.times = map[string]time.Time{}
+	}
+	c.times[key] = now
+}
+
+func createKey(r *http.Request, star *Star) string {
+	return clientIP(r) + "\x00" + star.Name + "\x00" + star.URL
+}
+
+// recentDuplicate returns the existing star if star repeats a create from
+// the same client within Config.CreateDedupWindow.
+func (a *App) recentDuplicate(r *http.Request, star *Star) (*Star, bool) {
+	if a.Config.CreateDedupWindow <= 0 {
+		return nil, false
+	}
+	if !a.creates.seen(createKey(r, star), time.Now(), a.Config.CreateDedupWindow) {
+		return nil, false
+	}
+
+	var existing Star
+	if err := a.DB.First(&existing, "name = ? AND url = ?", star.Name, star.URL).Error; err != nil {
+		return nil, false
+	}
+	return &existing, true
+}
+
+// recordCreate remembers a successful create for recentDuplicate.
+func (a *App) recordCreate(r *http.Request, star *Star) {
+	if a.Config.CreateDedupWindow > 0 {
+		a.creates.record(createKey(r, star), time.Now())
+	}
+}
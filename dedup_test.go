@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateDedup(t *testing.T) {
+	testStar := Star{Name: "test/name", Description: "test desc", URL: "test url"}
+
+	// Set up a test table.
+	dedupTests := []struct {
+		window   time.Duration
+		statuses []int
+	}{
+		{window: time.Minute, statuses: []int{http.StatusCreated, http.StatusOK}},
+		{window: 0, statuses: []int{http.StatusCreated, http.StatusConflict}},
+	}
+
+	for _, tt := range dedupTests {
+		app := setup()
+		app.Config.CreateDedupWindow = tt.window
+
+		// Submit the same create twice in quick succession.
+		for _, expected := range tt.statuses {
+			req, err := http.NewRequest("POST", "/stars", StarFormValues(testStar))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			req.RemoteAddr = "192.0.2.1:1234"
+
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(app.CreateHandler).ServeHTTP(rr, req)
+
+			// Test that the status code and Location header are correct.
+			if status := rr.Code; status != expected {
+				t.Errorf("Status code with window %s is invalid. Expected %d. Got %d instead", tt.window, expected, status)
+			}
+			expectedLocation := "/stars/test/name"
+			if expected == http.StatusConflict {
+				expectedLocation = ""
+			}
+			if location := rr.Header().Get("Location"); location != expectedLocation {
+				t.Errorf("Location header is invalid. Expected %q. Got %q instead", expectedLocation, location)
+			}
+		}
+
+		// Test that only a single star was created.
+		count := 0
+		app.DB.Model(&Star{}).Count(&count)
+		if count != 1 {
+			t.Errorf("Star count with window %s is invalid. Expected 1. Got %d instead", tt.window, count)
+		}
+
+		teardown(app)
+	}
+}
+
+func TestCreateDedupFailedCreate(t *testing.T) {
+	app := setup()
+	app.Config.CreateDedupWindow = time.Minute
+
+	// Someone else already has a star with this name and URL.
+	testStar := Star{Name: "test/name", Description: "test desc", URL: "test url"}
+	app.DB.Create(&Star{Name: testStar.Name, URL: testStar.URL})
+
+	// Test that a failed create doesn't count towards deduplication.
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", "/stars", StarFormValues(testStar))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.1:1234"
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.CreateHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusConflict, status)
+		}
+	}
+
+	teardown(app)
+}
+
+func TestRecentCreatesWindow(t *testing.T) {
+	creates := recentCreates{}
+	window := time.Minute
+	start := time.Now()
+
+	creates.record("key", start)
+
+	// Set up a test table of checks, in order. Duplicates don't extend the window.
+	checkTests := []struct {
+		after time.Duration
+		seen  bool
+	}{
+		{after: 30 * time.Second, seen: true},
+		{after: 50 * time.Second, seen: true},
+		{after: 70 * time.Second, seen: false},
+	}
+
+	for _, tt := range checkTests {
+		if seen := creates.seen("key", start.Add(tt.after), window); seen != tt.seen {
+			t.Errorf("Seen after %s is invalid. Expected %t. Got %t instead", tt.after, tt.seen, seen)
+		}
+	}
+	if creates.seen("other", start, window) {
+		t.Errorf("Unrecorded key was seen")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDeleteTokenTTL is used when Config.DeleteTokenTTL isn't set.
+const defaultDeleteTokenTTL = time.Minute
+
+// deleteToken allows a single delete of the named star until it expires.
+type deleteToken struct {
+	name    string
+	expires time.Time
+}
+
+// deleteTokenStore holds the outstanding delete tokens.
+type deleteTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]deleteToken
+}
+
+// issue creates a token for deleting the named star.
+func (s *deleteTokenStore) issue(name string, expires time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		s.tokens = map[string]deleteToken{}
+	}
+
+	// Drop expired tokens so the store doesn't grow without bound.
+	now := time.Now()
+	for token, t := range s.tokens {
+		if now.After(t.expires) {
+			delete(s.tokens, token)
+		}
+	}
+
+	token := newRequestID() + newRequestID()
+	s.tokens[token] = deleteToken{name: name, expires: expires}
+	return token
+}
+
+// valid reports whether token allows deleting the named star.
+func (s *deleteTokenStore) valid(token string, name string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	return ok && t.name == name && !now.After(t.expires)
+}
+
+// revoke uses up a token, once the delete it allowed has happened.
+func (s *deleteTokenStore) revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, token)
+}
+
+// DeleteRequestHandler issues a short-lived token that DeleteHandler
+// requires when Config.DeleteConfirmation is set.
+func (a *App) DeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Only hand out tokens for stars that exist.
+	if err := a.DB.First(&Star{}, "name = ?", vars["name"]).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	ttl := a.Config.DeleteTokenTTL
+	if ttl <= 0 {
+		ttl = defaultDeleteTokenTTL
+	}
+	expires := time.Now().Add(ttl)
+	tokenJSON, _ := json.Marshal(map[string]interface{}{
+		"token":      a.deleteTokens.issue(vars["name"], expires),
+		"expires_at": expires.UTC(),
+	})
+
+	// Write to HTTP response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(tokenJSON)
+}
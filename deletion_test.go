@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func deletionRouter(app *App) *mux.Router {
+	// We need a mux router in order to pass in the `name` variable.
+	r := mux.NewRouter()
+
+	r.HandleFunc("/stars/{name:.*}", app.DeleteHandler).Methods("DELETE")
+	r.HandleFunc("/stars/{name:.*}/delete-request", app.DeleteRequestHandler).Methods("POST")
+	return r
+}
+
+func requestDeleteToken(t *testing.T, app *App, name string) string {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/stars/%s/delete-request", name), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	deletionRouter(app).ServeHTTP(rr, req)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("Status code is invalid. Expected %d. Got %d instead", http.StatusCreated, status)
+	}
+
+	// Read the token from the response body.
+	body := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Token == "" {
+		t.Fatalf("Delete request response is invalid. Got: %s", rr.Body.String())
+	}
+	return body.Token
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	app := setup()
+	app.Config.DeleteConfirmation = true
+	app.Config.DeleteTokenTTL = 50 * time.Millisecond
+
+	// Create stars for us to delete.
+	app.DB.Create(&Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL"})
+	app.DB.Create(&Star{ID: 2, Name: "test/another_name", Description: "test desc 2", URL: "test URL"})
+
+	validToken := requestDeleteToken(t, app, "test/name")
+	otherToken := requestDeleteToken(t, app, "test/another_name")
+	expiredToken := requestDeleteToken(t, app, "test/another_name")
+	time.Sleep(2 * app.Config.DeleteTokenTTL)
+	freshToken := requestDeleteToken(t, app, "test/name")
+
+	// Set up a test table of delete attempts, in order.
+	deleteTests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "test/name", token: "", status: http.StatusForbidden},
+		{name: "test/name", token: "bogus", status: http.StatusForbidden},
+		{name: "test/name", token: otherToken, status: http.StatusForbidden},
+		{name: "test/another_name", token: expiredToken, status: http.StatusForbidden},
+		{name: "test/name", token: freshToken, status: http.StatusNoContent},
+		// Tokens can only be used once.
+		{name: "test/name", token: freshToken, status: http.StatusForbidden},
+	}
+
+	for _, tt := range deleteTests {
+		// Set up a new request.
+		req, err := http.NewRequest("DELETE", fmt.Sprintf("/stars/%s?token=%s", tt.name, tt.token), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		deletionRouter(app).ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != tt.status {
+			t.Errorf("Status code for token %q is invalid. Expected %d. Got %d instead", tt.token, tt.status, status)
+		}
+	}
+
+	// Test that only the star with a valid token was deleted.
+	count := 0
+	app.DB.Model(&Star{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Star count is invalid. Expected 1. Got %d instead", count)
+	}
+
+	// The first token expired unused along with the others.
+	if app.deleteTokens.valid(validToken, "test/name", time.Now()) {
+		t.Errorf("Expired token was accepted")
+	}
+
+	teardown(app)
+}
+
+func TestDeleteRequestHandlerNotFound(t *testing.T) {
+	app := setup()
+
+	// Set up a new request.
+	req, err := http.NewRequest("POST", "/stars/test/missing/delete-request", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	deletionRouter(app).ServeHTTP(rr, req)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusNotFound, status)
+	}
+
+	teardown(app)
+}
+
+func TestDeleteConfirmationFailedDelete(t *testing.T) {
+	app := setup()
+	app.Config.DeleteConfirmation = true
+
+	// Get a token, then have the star disappear before it's used.
+	app.DB.Create(&Star{ID: 1, Name: "test/name"})
+	token := requestDeleteToken(t, app, "test/name")
+	app.DB.Delete(&Star{ID: 1})
+
+	// Set up a test table of delete attempts, in order.
+	deleteTests := []struct {
+		create bool
+		status int
+	}{
+		{create: false, status: http.StatusNotFound},
+		// The failed delete didn't use up the token.
+		{create: true, status: http.StatusNoContent},
+	}
+
+	for _, tt := range deleteTests {
+		if tt.create {
+			app.DB.Create(&Star{Name: "test/name"})
+		}
+
+		req, err := http.NewRequest("DELETE", "/stars/test/name?token="+token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		deletionRouter(app).ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != tt.status {
+			t.Errorf("Status code is invalid. Expected %d. Got %d instead", tt.status, status)
+		}
+	}
+
+	teardown(app)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessLogEntry holds the fields recorded for each request, whatever the format.
+type accessLogEntry struct {
+	Time      time.Time
+	RemoteIP  string
+	Method    string
+	Path      string
+	Proto     string
+	Status    int
+	Bytes     int
+	Latency   time.Duration
+	RequestID string
+	Referer   string
+	UserAgent string
+}
+
+// accessLogFormats maps Config.AccessLogFormat names to their formatters.
+var accessLogFormats = map[string]func(accessLogEntry) string{
+	"combined": formatCombined,
+	"json":     formatJSON,
+	"logfmt":   formatLogfmt,
+}
+
+func formatCombined(e accessLogEntry) string {
+	// Apache combined log format, followed by latency in microseconds and the
+	// request ID. Quoted fields are escaped so clients can't end them early.
+	return fmt.Sprintf("%s - - [%s] %q %d %d %q %q %d %s",
+		e.RemoteIP, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+e.Path+" "+e.Proto, e.Status, e.Bytes,
+		orDash(e.Referer), orDash(e.UserAgent),
+		e.Latency.Nanoseconds()/1000, e.RequestID)
+}
+
+func formatJSON(e accessLogEntry) string {
+	line, _ := json.Marshal(map[string]interface{}{
+		"time":       e.Time.Format(time.RFC3339),
+		"remote_ip":  e.RemoteIP,
+		"method":     e.Method,
+		"path":       e.Path,
+		"status":     e.Status,
+		"bytes":      e.Bytes,
+		"latency_us": e.Latency.Nanoseconds() / 1000,
+		"request_id": e.RequestID,
+	})
+	return string(line)
+}
+
+func formatLogfmt(e accessLogEntry) string {
+	pairs := []string{
+		"time=" + e.Time.Format(time.RFC3339),
+		"remote_ip=" + logfmtValue(e.RemoteIP),
+		"method=" + logfmtValue(e.Method),
+		"path=" + logfmtValue(e.Path),
+		"status=" + strconv.Itoa(e.Status),
+		"bytes=" + strconv.Itoa(e.Bytes),
+		"latency_us=" + strconv.FormatInt(e.Latency.Nanoseconds()/1000, 10),
+		"request_id=" + logfmtValue(e.RequestID),
+	}
+	return strings.Join(pairs, " ")
+}
+
+func logfmtValue(s string) string {
+	// Quote values that would otherwise be split or misread.
+	if s == "" || strings.ContainsAny(s, " =\"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// loggingResponseWriter records the status code and body size of a response.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// clientIP returns the IP address a request came from.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// logPath returns the request URI for logging, with any delete
+// confirmation token redacted.
+func logPath(r *http.Request) string {
+	query := r.URL.Query()
+	if _, ok := query["token"]; !ok {
+		return r.URL.RequestURI()
+	}
+	query.Set("token", "REDACTED")
+
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// validRequestID matches client-supplied request IDs that are safe to log
+// unquoted.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LoggingMiddleware writes a line to AccessLog for each request, in the
+// format selected by Config.AccessLogFormat, and counts it for
+// TrafficStatsHandler.
+func (a *App) LoggingMiddleware(next http.Handler) http.Handler {
+	format, ok := accessLogFormats[a.Config.AccessLogFormat]
+	if !ok {
+		format = formatCombined
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Reuse the caller's request ID if there is one, so logs can be
+		// correlated, as long as it can't break the log line.
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+
+		latency := time.Since(start)
+		a.traffic.record(lw.status, lw.bytes, latency)
+
+		if a.AccessLog != nil {
+			io.WriteString(a.AccessLog, format(accessLogEntry{
+				Time:      start,
+				RemoteIP:  clientIP(r),
+				Method:    r.Method,
+				Path:      logPath(r),
+				Proto:     r.Proto,
+				Status:    lw.status,
+				Bytes:     lw.bytes,
+				Latency:   latency,
+				RequestID: requestID,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			})+"\n")
+		}
+	})
+}
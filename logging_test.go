@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	// Set up a test table.
+	formatTests := []struct {
+		format  string
+		path    string
+		pattern string
+	}{
+		{format: "combined", path: "/stars?q=x",
+			pattern: `^192\.0\.2\.1 - - \[[^\]]+\] "GET /stars\?q=x HTTP/1\.1" 418 5 "-" "-" \d+ test-id$`,
+		},
+		// Quotes and backslashes in the request are escaped, not left to end the field.
+		{format: "combined", path: `/stars?q=a"b\c`,
+			pattern: `^192\.0\.2\.1 - - \[[^\]]+\] "GET /stars\?q=a\\"b\\\\c HTTP/1\.1" 418 5 "-" "-" \d+ test-id$`,
+		},
+		{format: "logfmt", path: "/stars?q=x",
+			pattern: `^time=\S+ remote_ip=192\.0\.2\.1 method=GET path="/stars\?q=x" status=418 bytes=5 latency_us=\d+ request_id=test-id$`,
+		},
+	}
+
+	for _, tt := range formatTests {
+		var log bytes.Buffer
+		app := &App{Config: Config{AccessLogFormat: tt.format}, AccessLog: &log}
+
+		// Set up a new request.
+		req, err := http.NewRequest("GET", tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Request-ID", "test-id")
+
+		rr := httptest.NewRecorder()
+		app.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(418)
+			w.Write([]byte("hello"))
+		})).ServeHTTP(rr, req)
+
+		// Test that the logged line matches the format.
+		line := strings.TrimSuffix(log.String(), "\n")
+		if !regexp.MustCompile(tt.pattern).MatchString(line) {
+			t.Errorf("Log line is invalid for %s format. Got: %s", tt.format, line)
+		}
+	}
+}
+
+func TestLoggingMiddlewareJSON(t *testing.T) {
+	var log bytes.Buffer
+	app := &App{Config: Config{AccessLogFormat: "json"}, AccessLog: &log}
+
+	// Set up a new request with no request ID.
+	req, err := http.NewRequest("GET", "/stars", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	app.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})).ServeHTTP(rr, req)
+
+	// Test that the logged line is JSON with the expected fields.
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+		t.Fatalf("Log line is invalid JSON. Got: %s", log.String())
+	}
+	expected := map[string]interface{}{
+		"method":     "GET",
+		"path":       "/stars",
+		"status":     float64(200),
+		"bytes":      float64(2),
+		"request_id": rr.Header().Get("X-Request-ID"),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Log field %s is invalid. Expected %v. Got %v instead", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["latency_us"]; !ok {
+		t.Errorf("Log line is missing latency_us. Got: %s", log.String())
+	}
+	if entry["request_id"] == "" {
+		t.Errorf("Request ID was not generated")
+	}
+}
+
+func TestLoggingMiddlewareRedactsToken(t *testing.T) {
+	var log bytes.Buffer
+	app := &App{Config: Config{AccessLogFormat: "combined"}, AccessLog: &log}
+
+	// Set up a new request carrying a delete confirmation token.
+	req, err := http.NewRequest("DELETE", "/stars/test/name?token=s3cret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	// Test that the token was redacted.
+	if strings.Contains(log.String(), "s3cret") || !strings.Contains(log.String(), "/stars/test/name?token=REDACTED") {
+		t.Errorf("Log line should redact the token. Got: %s", log.String())
+	}
+}
+
+func TestLoggingMiddlewareRequestID(t *testing.T) {
+	// Set up a test table of client request IDs, and whether they should be kept.
+	idTests := []struct {
+		id   string
+		kept bool
+	}{
+		{id: "abc-123_x.y", kept: true},
+		{id: "has space", kept: false},
+		{id: `has"quote`, kept: false},
+		{id: strings.Repeat("a", 65), kept: false},
+	}
+
+	for _, tt := range idTests {
+		var log bytes.Buffer
+		app := &App{Config: Config{AccessLogFormat: "combined"}, AccessLog: &log}
+
+		req, err := http.NewRequest("GET", "/stars", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Request-ID", tt.id)
+
+		rr := httptest.NewRecorder()
+		app.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+		// Test that invalid IDs were replaced with a generated one.
+		id := rr.Header().Get("X-Request-ID")
+		if kept := id == tt.id; kept != tt.kept {
+			t.Errorf("Request ID %q kept is invalid. Expected %t. Got ID %q instead", tt.id, tt.kept, id)
+		}
+		if !validRequestID.MatchString(id) || !strings.HasSuffix(log.String(), " "+id+"\n") {
+			t.Errorf("Logged request ID is invalid. Got: %s", log.String())
+		}
+	}
+}
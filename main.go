@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
@@ -16,10 +24,74 @@ type Star struct {
 	Name        string `gorm:"unique" json:"name"`
 	Description string `json:"description"`
 	URL         string `json:"url"`
+
+	Read   bool       `json:"read"`
+	ReadAt *time.Time `json:"read_at"`
+
+	// Submission metadata, recorded when Config.RecordSubmitter is set.
+	// These are only returned to admins, by AdminViewHandler.
+	SubmitterIP        string `json:"-"`
+	SubmitterUserAgent string `json:"-"`
+}
+
+// Config holds optional settings that change how the App behaves.
+type Config struct {
+	// DBKey is the passphrase for an encrypted (SQLCipher) database. When
+	// empty, the database is opened as plaintext.
+	DBKey string
+
+	// AdminToken is the bearer token required by the /admin routes. When
+	// empty, the admin routes are disabled.
+	AdminToken string
+
+	// AccessLogFormat selects the access log line format: "combined"
+	// (the default), "json", or "logfmt".
+	AccessLogFormat string
+
+	// BackupDir, if set, enables periodic JSON backups of all stars to
+	// this directory, every BackupInterval. Only the newest BackupKeep
+	// backups are kept. Backups are plaintext, so they can't be combined
+	// with DBKey.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// DeleteConfirmation makes DeleteHandler require a token from
+	// DeleteRequestHandler, valid for DeleteTokenTTL.
+	DeleteConfirmation bool
+	DeleteTokenTTL     time.Duration
+
+	// MaxResponseBytes caps the size of a ListHandler response. Zero means
+	// no limit.
+	MaxResponseBytes int
+
+	// CreateDedupWindow, if set, makes a create with the same name and URL
+	// from the same client within this window return the existing star
+	// rather than a conflict.
+	CreateDedupWindow time.Duration
+
+	// RecordSubmitter stores the client IP and User-Agent that created
+	// each star, for auditing.
+	RecordSubmitter bool
+
+	// TLSCertFile and TLSKeyFile, if set, make the server use TLS, with at
+	// least TLSMinVersion ("1.2" or "1.3", default "1.2") and, for TLS 1.2,
+	// only the named TLSCipherSuites (default: Go's secure defaults).
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSMinVersion   string
+	TLSCipherSuites []string
 }
 
 type App struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Config    Config
+	AccessLog io.Writer
+
+	traffic      trafficStats
+	deleteTokens deleteTokenStore
+	creates      recentCreates
+	searchIndex  bool
 }
 
 func (a *App) Initialize(dbDriver string, dbURI string) {
@@ -27,20 +99,157 @@ func (a *App) Initialize(dbDriver string, dbURI string) {
 	if err != nil {
 		panic("failed to connect database")
 	}
+
+	if a.Config.DBKey != "" {
+		// Plain SQLite silently ignores PRAGMA key, which would leave the
+		// data unencrypted, so make sure this is really SQLCipher.
+		var version string
+		if err := db.Raw("PRAGMA cipher_version").Row().Scan(&version); err != nil || version == "" {
+			db.Close()
+			panic("failed to open encrypted database: sqlite3 driver lacks SQLCipher support")
+		}
+
+		// SQLCipher needs the key set on each connection, so only use one.
+		db.DB().SetMaxOpenConns(1)
+		if err := db.Exec(fmt.Sprintf("PRAGMA key = '%s'", strings.Replace(a.Config.DBKey, "'", "''", -1))).Error; err != nil {
+			db.Close()
+			panic("failed to open encrypted database: could not set key")
+		}
+
+		// The key isn't checked until the database is first read.
+		if err := db.Exec("SELECT count(*) FROM sqlite_master").Error; err != nil {
+			db.Close()
+			panic("failed to open encrypted database: wrong key?")
+		}
+	}
 	a.DB = db
 
 	// Migrate the schema.
 	a.DB.AutoMigrate(&Star{})
+	a.createSearchIndex()
+}
+
+// writeError writes a JSON error body, like {"error": "star not found"}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	errorJSON, _ := json.Marshal(map[string]string{"error": message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(errorJSON)
+}
+
+// writeDBError maps a gorm error to an error response.
+func writeDBError(w http.ResponseWriter, err error) {
+	if gorm.IsRecordNotFoundError(err) {
+		writeError(w, http.StatusNotFound, "star not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "database error")
+}
+
+// writeSaveError maps an error from saving a star with the given name to an
+// error response, reporting a conflict if that name is already taken.
+func (a *App) writeSaveError(w http.ResponseWriter, err error, name string) {
+	if !a.DB.First(&Star{}, "name = ?", name).RecordNotFound() {
+		writeError(w, http.StatusConflict, "star already exists")
+		return
+	}
+	writeDBError(w, err)
+}
+
+// Pagination limits for ListHandler.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// listOrders maps the values accepted by ListHandler's `sort` parameter to
+// ORDER BY clauses.
+var listOrders = map[string]string{
+	"":      "stars.id asc",
+	"name":  "stars.name asc",
+	"-name": "stars.name desc",
+}
+
+// queryInt parses a non-negative integer query parameter, falling back to
+// def when it is missing.
+func queryInt(query url.Values, key string, def int) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
 }
 
 func (a *App) ListHandler(w http.ResponseWriter, r *http.Request) {
-	var stars []Star
+	stars := []Star{}
+	query := r.URL.Query()
 
-	// Select all stars and convert to JSON.
-	a.DB.Find(&stars)
+	// Validate the query parameters.
+	limit, err := queryInt(query, "limit", defaultListLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	offset, err := queryInt(query, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, ok := listOrders[query.Get("sort")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "sort must be name or -name")
+		return
+	}
+	filterRead := query.Get("unread") != ""
+	unread, err := strconv.ParseBool(query.Get("unread"))
+	if filterRead && err != nil {
+		writeError(w, http.StatusBadRequest, "unread must be true or false")
+		return
+	}
+
+	// Filter by the search term, if there is one, with the most relevant
+	// stars first unless another order was asked for.
+	db := a.DB.Model(&Star{})
+	if q := query.Get("q"); q != "" {
+		var ranked bool
+		db, ranked = a.searchFilter(db, q)
+		if ranked && query.Get("sort") == "" {
+			order = searchRankOrder
+		}
+	}
+
+	// Filter by read state: unread=true for unread stars only, unread=false
+	// for read ones. Stars from before read state existed have NULL there.
+	if filterRead {
+		db = db.Where("COALESCE(read, ?) = ?", false, !unread)
+	}
+
+	// Count all matching stars, then select the requested page and convert to JSON.
+	total := 0
+	if err := db.Count(&total).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := db.Order(order).Limit(limit).Offset(offset).Find(&stars).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
 	starsJSON, _ := json.Marshal(stars)
+	if a.Config.MaxResponseBytes > 0 && len(starsJSON) > a.Config.MaxResponseBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "response too large; use a smaller limit to paginate")
+		return
+	}
 
 	// Write to HTTP response.
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	w.WriteHeader(200)
 	w.Write([]byte(starsJSON))
 }
@@ -50,7 +259,10 @@ func (a *App) ViewHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	// Select the star with the given name, and convert to JSON.
-	a.DB.First(&star, "name = ?", vars["name"])
+	if err := a.DB.First(&star, "name = ?", vars["name"]).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
 	starJSON, _ := json.Marshal(star)
 
 	// Write to HTTP response.
@@ -61,7 +273,8 @@ func (a *App) ViewHandler(w http.ResponseWriter, r *http.Request) {
 func (a *App) CreateHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the POST body to populate r.PostForm.
 	if err := r.ParseForm(); err != nil {
-		panic("failed in ParseForm() call")
+		writeError(w, http.StatusBadRequest, "invalid form body")
+		return
 	}
 
 	// Create a new star from the request body.
@@ -70,20 +283,41 @@ func (a *App) CreateHandler(w http.ResponseWriter, r *http.Request) {
 		Description: r.PostFormValue("description"),
 		URL:         r.PostFormValue("url"),
 	}
-	a.DB.Create(star)
+	if a.Config.RecordSubmitter {
+		star.SubmitterIP = clientIP(r)
+		star.SubmitterUserAgent = r.UserAgent()
+	}
 
-	// Form the URL of the newly created star.
+	// Form the URL of the new star.
 	u, err := url.Parse(fmt.Sprintf("/stars/%s", star.Name))
 	if err != nil {
-		panic("failed to form new Star URL")
+		writeError(w, http.StatusInternalServerError, "failed to form new star URL")
+		return
 	}
 	base, err := url.Parse(r.URL.String())
 	if err != nil {
-		panic("failed to parse request URL")
+		writeError(w, http.StatusInternalServerError, "failed to parse request URL")
+		return
+	}
+	location := base.ResolveReference(u).String()
+
+	// Return the existing star if this is an accidental resubmission.
+	if existing, ok := a.recentDuplicate(r, star); ok {
+		starJSON, _ := json.Marshal(existing)
+		w.Header().Set("Location", location)
+		w.WriteHeader(200)
+		w.Write([]byte(starJSON))
+		return
 	}
 
+	if err := a.DB.Create(star).Error; err != nil {
+		a.writeSaveError(w, err, star.Name)
+		return
+	}
+	a.recordCreate(r, star)
+
 	// Write to HTTP response.
-	w.Header().Set("Location", base.ResolveReference(u).String())
+	w.Header().Set("Location", location)
 	w.WriteHeader(201)
 }
 
@@ -92,7 +326,8 @@ func (a *App) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the POST body to populate r.PostForm.
 	if err := r.ParseForm(); err != nil {
-		panic("failed in ParseForm() call")
+		writeError(w, http.StatusBadRequest, "invalid form body")
+		return
 	}
 
 	// Set new star values from the request body.
@@ -102,8 +337,17 @@ func (a *App) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 		URL:         r.PostFormValue("url"),
 	}
 
+	// Make sure the star exists before updating it.
+	if err := a.DB.First(&Star{}, "name = ?", vars["name"]).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+
 	// Update the star with the given name.
-	a.DB.Model(&star).Where("name = ?", vars["name"]).Updates(&star)
+	if err := a.DB.Model(&star).Where("name = ?", vars["name"]).Updates(&star).Error; err != nil {
+		a.writeSaveError(w, err, star.Name)
+		return
+	}
 
 	// Write to HTTP response.
 	w.WriteHeader(204)
@@ -112,30 +356,152 @@ func (a *App) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 func (a *App) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
+	// In confirmation mode, only delete with a token from DeleteRequestHandler.
+	token := r.URL.Query().Get("token")
+	if a.Config.DeleteConfirmation && !a.deleteTokens.valid(token, vars["name"], time.Now()) {
+		writeError(w, http.StatusForbidden, "missing or expired delete token")
+		return
+	}
+
 	// Delete the star with the given name.
-	a.DB.Where("name = ?", vars["name"]).Delete(Star{})
+	result := a.DB.Where("name = ?", vars["name"]).Delete(Star{})
+	if result.Error != nil {
+		writeDBError(w, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, http.StatusNotFound, "star not found")
+		return
+	}
+
+	// Only use up the token once the star is actually gone.
+	if a.Config.DeleteConfirmation {
+		a.deleteTokens.revoke(token)
+	}
 
 	// Write to HTTP response.
 	w.WriteHeader(204)
 }
 
-func main() {
-	a := &App{}
-	a.Initialize("sqlite3", "test.db")
-
+// Routes sets up the API routes. Admin routes require Config.AdminToken.
+func (a *App) Routes() *mux.Router {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/stars", a.ListHandler).Methods("GET")
+	r.HandleFunc("/stars/checksum", a.ChecksumHandler).Methods("GET")
 	r.HandleFunc("/stars/{name:.+}", a.ViewHandler).Methods("GET")
 	r.HandleFunc("/stars", a.CreateHandler).Methods("POST")
 	r.HandleFunc("/stars/{name:.+}", a.UpdateHandler).Methods("PUT")
 	r.HandleFunc("/stars/{name:.+}", a.DeleteHandler).Methods("DELETE")
+	r.HandleFunc("/stars/{name:.+}/delete-request", a.DeleteRequestHandler).Methods("POST")
+	r.HandleFunc("/stars/{name:.+}/read", a.ReadHandler).Methods("POST")
+	r.HandleFunc("/stars/{name:.+}/unread", a.UnreadHandler).Methods("POST")
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(a.AdminMiddleware)
+	admin.HandleFunc("/stats/traffic", a.TrafficStatsHandler).Methods("GET")
+	admin.HandleFunc("/validate-urls", a.ValidateURLsHandler).Methods("GET")
+	admin.HandleFunc("/stars/{name:.+}", a.AdminViewHandler).Methods("GET")
+	admin.HandleFunc("/reindex-search", a.ReindexSearchHandler).Methods("POST")
+
+	return r
+}
+
+func main() {
+	a := &App{
+		Config: Config{
+			DBKey:              os.Getenv("STARMANAGER_DB_KEY"),
+			AdminToken:         os.Getenv("STARMANAGER_ADMIN_TOKEN"),
+			AccessLogFormat:    os.Getenv("STARMANAGER_ACCESS_LOG_FORMAT"),
+			BackupDir:          os.Getenv("STARMANAGER_BACKUP_DIR"),
+			BackupInterval:     24 * time.Hour,
+			BackupKeep:         7,
+			DeleteConfirmation: os.Getenv("STARMANAGER_DELETE_CONFIRMATION") != "",
+			RecordSubmitter:    os.Getenv("STARMANAGER_RECORD_SUBMITTER") != "",
+			TLSCertFile:        os.Getenv("STARMANAGER_TLS_CERT"),
+			TLSKeyFile:         os.Getenv("STARMANAGER_TLS_KEY"),
+			TLSMinVersion:      os.Getenv("STARMANAGER_TLS_MIN_VERSION"),
+		},
+		AccessLog: os.Stdout,
+	}
+	if a.Config.AccessLogFormat == "" {
+		a.Config.AccessLogFormat = "combined"
+	}
+	if _, ok := accessLogFormats[a.Config.AccessLogFormat]; !ok {
+		panic("unknown access log format: " + a.Config.AccessLogFormat)
+	}
+	if interval := os.Getenv("STARMANAGER_BACKUP_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			panic("invalid backup interval: " + interval)
+		}
+		a.Config.BackupInterval = d
+	}
+	if keep := os.Getenv("STARMANAGER_BACKUP_KEEP"); keep != "" {
+		n, err := strconv.Atoi(keep)
+		if err != nil || n < 1 {
+			panic("invalid backup count: " + keep)
+		}
+		a.Config.BackupKeep = n
+	}
+	if max := os.Getenv("STARMANAGER_MAX_RESPONSE_BYTES"); max != "" {
+		n, err := strconv.Atoi(max)
+		if err != nil || n < 0 {
+			panic("invalid maximum response size: " + max)
+		}
+		a.Config.MaxResponseBytes = n
+	}
+	if window := os.Getenv("STARMANAGER_CREATE_DEDUP_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d < 0 {
+			panic("invalid create dedup window: " + window)
+		}
+		a.Config.CreateDedupWindow = d
+	}
+	if suites := os.Getenv("STARMANAGER_TLS_CIPHER_SUITES"); suites != "" {
+		a.Config.TLSCipherSuites = strings.Split(suites, ",")
+	}
+	if a.Config.DBKey != "" && a.Config.BackupDir != "" {
+		panic("backups are plaintext JSON, so can't be used with an encrypted database")
+	}
+	tlsConfig, err := a.Config.TLSConfig()
+	if err != nil {
+		panic(err)
+	}
+	a.Initialize("sqlite3", "test.db")
+	defer a.DB.Close()
+
+	if a.Config.BackupDir != "" {
+		stopBackups := a.StartBackups()
+		defer stopBackups()
+	}
+
+	r := a.Routes()
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./build/"))).Methods("GET")
 
-	http.Handle("/", r)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	srv := &http.Server{Addr: ":8080", Handler: a.LoggingMiddleware(r), TLSConfig: tlsConfig}
+
+	// Shut down cleanly on interrupt, so deferred cleanup gets to run.
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		srv.Shutdown(context.Background())
+	}()
+
+	if a.Config.TLSCertFile != "" {
+		err = srv.ListenAndServeTLS(a.Config.TLSCertFile, a.Config.TLSKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		panic(err)
 	}
 
-	defer a.DB.Close()
+	// ListenAndServe returns as soon as shutdown starts, so wait for
+	// in-flight requests to finish before closing the database.
+	<-shutdown
 }
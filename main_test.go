@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -239,6 +241,168 @@ func TestListHandler(t *testing.T) {
 	teardown(app)
 }
 
+func TestListHandlerQuery(t *testing.T) {
+	app := setup()
+
+	// Create some stars to search, sort, and page through.
+	stars := []Star{
+		Star{ID: 1, Name: "b/widget", Description: "A Go library", URL: "test URL"},
+		Star{ID: 2, Name: "a/golang", Description: "compiler", URL: "test URL"},
+		Star{ID: 3, Name: "c/parser", Description: "100% pure", URL: "test URL"},
+		Star{ID: 4, Name: "d/other", Description: "nothing", URL: "test URL"},
+	}
+	for _, star := range stars {
+		app.DB.Create(star)
+	}
+
+	// Set up a test table of queries and the IDs they should return, in order.
+	queryTests := []struct {
+		query string
+		ids   []uint
+		total string
+	}{
+		{query: "", ids: []uint{1, 2, 3, 4}, total: "4"},
+		// Unsorted searches may be ordered by relevance, so give an order.
+		{query: "q=go&sort=-name", ids: []uint{1, 2}, total: "2"},
+		{query: "q=GOLANG", ids: []uint{2}, total: "1"},
+		{query: "q=%25", ids: []uint{3}, total: "1"},
+		{query: "q=missing", ids: []uint{}, total: "0"},
+		{query: "sort=name", ids: []uint{2, 1, 3, 4}, total: "4"},
+		{query: "sort=-name", ids: []uint{4, 3, 1, 2}, total: "4"},
+		{query: "limit=2", ids: []uint{1, 2}, total: "4"},
+		{query: "limit=2&offset=3", ids: []uint{4}, total: "4"},
+		{query: "limit=0", ids: []uint{}, total: "4"},
+		{query: "q=go&sort=name&limit=1", ids: []uint{2}, total: "2"},
+		{query: "q=go&sort=-name&limit=1&offset=1", ids: []uint{2}, total: "2"},
+	}
+
+	for _, tt := range queryTests {
+		// Set up a new request.
+		req, err := http.NewRequest("GET", "/stars?"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+		// Test that the status code and total count are correct.
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Status code for %q is invalid. Expected %d. Got %d instead", tt.query, http.StatusOK, status)
+		}
+		if total := rr.Header().Get("X-Total-Count"); total != tt.total {
+			t.Errorf("X-Total-Count for %q is invalid. Expected %s. Got %s instead", tt.query, tt.total, total)
+		}
+
+		// Test that the returned stars are correct.
+		returnedStars := []Star{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &returnedStars); err != nil {
+			t.Errorf("Returned star list for %q is invalid JSON. Got: %s", tt.query, rr.Body.String())
+		}
+		ids := []uint{}
+		for _, star := range returnedStars {
+			ids = append(ids, star.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+			t.Errorf("Returned stars for %q are invalid. Expected IDs %v. Got %v instead", tt.query, tt.ids, ids)
+		}
+	}
+
+	teardown(app)
+}
+
+func TestListHandlerBadQuery(t *testing.T) {
+	app := setup()
+
+	for _, query := range []string{"limit=-1", "limit=ten", "offset=-5", "offset=1.5", "sort=description"} {
+		// Set up a new request.
+		req, err := http.NewRequest("GET", "/stars?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Status code for %q is invalid. Expected %d. Got %d instead", query, http.StatusBadRequest, status)
+		}
+	}
+
+	teardown(app)
+}
+
+func TestListHandlerMaxLimit(t *testing.T) {
+	app := setup()
+
+	// Create more stars than a single page may hold.
+	for i := 0; i < maxListLimit+1; i++ {
+		app.DB.Create(&Star{Name: fmt.Sprintf("test/name%d", i)})
+	}
+
+	// Set up a new request asking for everything.
+	req, err := http.NewRequest("GET", fmt.Sprintf("/stars?limit=%d", maxListLimit*2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+	// Test that the page was capped.
+	returnedStars := []Star{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &returnedStars); err != nil {
+		t.Errorf("Returned star list is invalid JSON. Got: %s", rr.Body.String())
+	}
+	if len(returnedStars) != maxListLimit {
+		t.Errorf("Returned star list is an invalid length. Expected %d. Got %d instead", maxListLimit, len(returnedStars))
+	}
+
+	teardown(app)
+}
+
+func TestListHandlerMaxResponseBytes(t *testing.T) {
+	app := setup()
+	app.Config.MaxResponseBytes = 200
+
+	// Create enough stars to go over budget.
+	for i := 0; i < 5; i++ {
+		app.DB.Create(&Star{Name: fmt.Sprintf("test/name%d", i), Description: "test desc", URL: "test URL"})
+	}
+
+	// Set up a test table.
+	queryTests := []struct {
+		query  string
+		status int
+	}{
+		{query: "", status: http.StatusRequestEntityTooLarge},
+		{query: "limit=1", status: http.StatusOK},
+	}
+
+	for _, tt := range queryTests {
+		// Set up a new request.
+		req, err := http.NewRequest("GET", "/stars?"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != tt.status {
+			t.Errorf("Status code for %q is invalid. Expected %d. Got %d instead", tt.query, tt.status, status)
+		}
+	}
+
+	teardown(app)
+}
+
 func TestDeleteHandler(t *testing.T) {
 	app := setup()
 
@@ -282,3 +446,230 @@ func TestDeleteHandler(t *testing.T) {
 
 	teardown(app)
 }
+
+func TestCreateHandlerMalformedBody(t *testing.T) {
+	app := setup()
+
+	// Set up a new request with an invalid URL escape in the body.
+	req, err := http.NewRequest("POST", "/stars", strings.NewReader("name=%zz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.CreateHandler).ServeHTTP(rr, req)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusBadRequest, status)
+	}
+
+	// Test that nothing was created.
+	count := 0
+	app.DB.Model(&Star{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Star count is invalid. Expected 0. Got %d instead", count)
+	}
+
+	teardown(app)
+}
+
+func TestNotFound(t *testing.T) {
+	app := setup()
+
+	// Create a star that doesn't match any of the requests.
+	app.DB.Create(&Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL"})
+
+	// Set up a test table.
+	handlerTests := []struct {
+		method  string
+		handler http.HandlerFunc
+	}{
+		{method: "GET", handler: app.ViewHandler},
+		{method: "PUT", handler: app.UpdateHandler},
+		{method: "DELETE", handler: app.DeleteHandler},
+	}
+
+	for _, tt := range handlerTests {
+		// Set up a new request.
+		body := StarFormValues(Star{Name: "test/other_name", Description: "updated desc"})
+		req, err := http.NewRequest(tt.method, "/stars/test/missing", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		// We need a mux router in order to pass in the `name` variable.
+		r := mux.NewRouter()
+
+		r.HandleFunc("/stars/{name:.*}", tt.handler).Methods(tt.method)
+		r.ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("%s status code is invalid. Expected %d. Got %d instead", tt.method, http.StatusNotFound, status)
+		}
+
+		// Test that the error body is correct.
+		expected := `{"error":"star not found"}`
+		if body := rr.Body.String(); body != expected {
+			t.Errorf("%s error body is invalid. Expected %s. Got %s instead", tt.method, expected, body)
+		}
+	}
+
+	// Test that the existing star was left alone.
+	existingStar := Star{}
+	app.DB.First(&existingStar)
+	if existingStar.Name != "test/name" || existingStar.Description != "test desc" {
+		t.Errorf("Existing star was modified: %+v", existingStar)
+	}
+
+	teardown(app)
+}
+
+func TestConflict(t *testing.T) {
+	app := setup()
+
+	// Create stars whose names are already taken.
+	app.DB.Create(&Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL"})
+	app.DB.Create(&Star{ID: 2, Name: "test/other_name", Description: "test desc 2", URL: "test URL"})
+
+	// Set up a test table.
+	handlerTests := []struct {
+		method  string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{method: "POST", path: "/stars", handler: app.CreateHandler},
+		// Renaming a star to a taken name conflicts too.
+		{method: "PUT", path: "/stars/test/other_name", handler: app.UpdateHandler},
+	}
+
+	for _, tt := range handlerTests {
+		// Set up a new request.
+		body := StarFormValues(Star{Name: "test/name", Description: "updated desc"})
+		req, err := http.NewRequest(tt.method, tt.path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		// We need a mux router in order to pass in the `name` variable.
+		r := mux.NewRouter()
+
+		r.HandleFunc("/stars", tt.handler).Methods(tt.method)
+		r.HandleFunc("/stars/{name:.*}", tt.handler).Methods(tt.method)
+		r.ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("%s status code is invalid. Expected %d. Got %d instead", tt.method, http.StatusConflict, status)
+		}
+
+		// Test that the error body is correct.
+		expected := `{"error":"star already exists"}`
+		if body := rr.Body.String(); body != expected {
+			t.Errorf("%s error body is invalid. Expected %s. Got %s instead", tt.method, expected, body)
+		}
+	}
+
+	// Test that the existing stars were left alone.
+	stars := []Star{}
+	app.DB.Order("id").Find(&stars)
+	if len(stars) != 2 || stars[0].Description != "test desc" || stars[1].Name != "test/other_name" {
+		t.Errorf("Existing stars were modified: %+v", stars)
+	}
+
+	teardown(app)
+}
+
+func sqlcipherAvailable() bool {
+	// SQLCipher builds report a cipher_version; plain SQLite returns nothing.
+	app := setup()
+	defer teardown(app)
+
+	var version string
+	if err := app.DB.Raw("PRAGMA cipher_version").Row().Scan(&version); err != nil {
+		return false
+	}
+	return version != ""
+}
+
+func openEncrypted(path string, key string) (app *App, err error) {
+	// Initialize panics on failure, so turn that back into an error.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	app = &App{Config: Config{DBKey: key}}
+	app.Initialize("sqlite3", path)
+	return app, nil
+}
+
+func TestEncryptedDatabase(t *testing.T) {
+	if !sqlcipherAvailable() {
+		t.Skip("sqlite3 driver was not built with SQLCipher support")
+	}
+
+	dir, err := ioutil.TempDir("", "starmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "encrypted.db")
+
+	// Create an encrypted database with a star in it.
+	testStar := Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL"}
+	app, err := openEncrypted(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.DB.Create(&testStar)
+	teardown(app)
+
+	// Test that reopening with the correct key returns the star.
+	app, err = openEncrypted(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to reopen with the correct key: %s", err)
+	}
+	storedStar := Star{}
+	app.DB.First(&storedStar)
+	if storedStar != testStar {
+		t.Errorf("Stored star is invalid. Expected %+v. Got %+v instead", testStar, storedStar)
+	}
+	teardown(app)
+
+	// Test that reopening with the wrong key fails.
+	if app, err := openEncrypted(path, "battery staple"); err == nil {
+		teardown(app)
+		t.Errorf("Opening with the wrong key should fail")
+	}
+}
+
+func TestEncryptedDatabaseUnsupported(t *testing.T) {
+	if sqlcipherAvailable() {
+		t.Skip("sqlite3 driver was built with SQLCipher support")
+	}
+
+	dir, err := ioutil.TempDir("", "starmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Test that asking for encryption fails rather than silently storing plaintext.
+	app, err := openEncrypted(filepath.Join(dir, "encrypted.db"), "correct horse")
+	if err == nil {
+		teardown(app)
+		t.Fatalf("Opening an encrypted database without SQLCipher should fail")
+	}
+	if !strings.Contains(err.Error(), "SQLCipher") {
+		t.Errorf("Error is invalid. Expected a mention of SQLCipher. Got %q instead", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadHandler marks a star as read, recording when.
+func (a *App) ReadHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	a.setRead(w, r, true, &now)
+}
+
+// UnreadHandler marks a star as unread, clearing its read time.
+func (a *App) UnreadHandler(w http.ResponseWriter, r *http.Request) {
+	a.setRead(w, r, false, nil)
+}
+
+func (a *App) setRead(w http.ResponseWriter, r *http.Request, read bool, readAt *time.Time) {
+	var star Star
+	vars := mux.Vars(r)
+
+	// Select the star with the given name.
+	if err := a.DB.First(&star, "name = ?", vars["name"]).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	// Update its read state. A map is used so that false and nil are saved too.
+	if err := a.DB.Model(&star).Updates(map[string]interface{}{"read": read, "read_at": readAt}).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+	starJSON, _ := json.Marshal(star)
+
+	// Write to HTTP response.
+	w.WriteHeader(200)
+	w.Write([]byte(starJSON))
+}
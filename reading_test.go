@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestReadHandlers(t *testing.T) {
+	app := setup()
+
+	// Create a star for us to toggle.
+	app.DB.Create(&Star{ID: 1, Name: "test/name", Description: "test desc", URL: "test URL"})
+
+	// Set up a test table of toggles, in order.
+	toggleTests := []struct {
+		action string
+		read   bool
+	}{
+		{action: "read", read: true},
+		{action: "unread", read: false},
+		{action: "read", read: true},
+	}
+
+	for _, tt := range toggleTests {
+		before := time.Now()
+
+		// Set up a new request.
+		req, err := http.NewRequest("POST", fmt.Sprintf("/stars/test/name/%s", tt.action), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		// We need a mux router in order to pass in the `name` variable.
+		r := mux.NewRouter()
+
+		r.HandleFunc("/stars/{name:.*}/read", app.ReadHandler).Methods("POST")
+		r.HandleFunc("/stars/{name:.*}/unread", app.UnreadHandler).Methods("POST")
+		r.ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusOK, status)
+		}
+
+		// Test that the returned and stored stars are correct.
+		returnedStar := Star{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &returnedStar); err != nil {
+			t.Errorf("Returned star is invalid JSON. Got: %s", rr.Body.String())
+		}
+		storedStar := Star{}
+		app.DB.First(&storedStar)
+
+		for _, star := range []Star{returnedStar, storedStar} {
+			if star.Read != tt.read {
+				t.Errorf("Read state after %s is invalid. Expected %t. Got %t instead", tt.action, tt.read, star.Read)
+			}
+			if tt.read && (star.ReadAt == nil || star.ReadAt.Before(before.Add(-time.Second))) {
+				t.Errorf("ReadAt after %s is invalid. Expected around %s. Got %v instead", tt.action, before, star.ReadAt)
+			}
+			if !tt.read && star.ReadAt != nil {
+				t.Errorf("ReadAt after %s is invalid. Expected nil. Got %v instead", tt.action, star.ReadAt)
+			}
+		}
+	}
+
+	teardown(app)
+}
+
+func TestListHandlerUnread(t *testing.T) {
+	app := setup()
+
+	// Create a mix of read and unread stars.
+	readAt := time.Now()
+	app.DB.Create(&Star{ID: 1, Name: "test/unread"})
+	app.DB.Create(&Star{ID: 2, Name: "test/read", Read: true, ReadAt: &readAt})
+	app.DB.Create(&Star{ID: 3, Name: "test/also_unread"})
+
+	// Set up a test table.
+	queryTests := []struct {
+		query string
+		ids   []uint
+	}{
+		{query: "", ids: []uint{1, 2, 3}},
+		{query: "unread=true", ids: []uint{1, 3}},
+		{query: "unread=false", ids: []uint{2}},
+	}
+
+	for _, tt := range queryTests {
+		// Set up a new request.
+		req, err := http.NewRequest("GET", "/stars?"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+		// Test that the returned stars are correct.
+		returnedStars := []Star{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &returnedStars); err != nil {
+			t.Errorf("Returned star list for %q is invalid JSON. Got: %s", tt.query, rr.Body.String())
+		}
+		ids := []uint{}
+		for _, star := range returnedStars {
+			ids = append(ids, star.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+			t.Errorf("Returned stars for %q are invalid. Expected IDs %v. Got %v instead", tt.query, tt.ids, ids)
+		}
+	}
+
+	teardown(app)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// searchIndexSQL creates an SQLite FTS5 index over star names and
+// descriptions, kept in sync with the stars table by triggers.
+var searchIndexSQL = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS stars_fts USING fts5(name, description, content='stars', content_rowid='id')`,
+	`CREATE TRIGGER IF NOT EXISTS stars_fts_insert AFTER INSERT ON stars BEGIN
+		INSERT INTO stars_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS stars_fts_delete AFTER DELETE ON stars BEGIN
+		INSERT INTO stars_fts(stars_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS stars_fts_update AFTER UPDATE ON stars BEGIN
+		INSERT INTO stars_fts(stars_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+		INSERT INTO stars_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+}
+
+// searchIndexTriggers are the triggers created by searchIndexSQL. Builds
+// without FTS5 can't run them, so they are dropped when it's missing.
+var searchIndexTriggers = []string{"stars_fts_insert", "stars_fts_delete", "stars_fts_update"}
+
+// fts5Available reports whether the sqlite3 driver was built with FTS5.
+var fts5Available = func(db *gorm.DB) bool {
+	var used bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Row().Scan(&used); err != nil {
+		return false
+	}
+	return used
+}
+
+// createSearchIndex sets up the full-text search index, if the database
+// supports it. Otherwise searches fall back to LIKE.
+func (a *App) createSearchIndex() {
+	if a.DB.Dialect().GetName() != "sqlite3" {
+		return
+	}
+
+	// The database may have been indexed by a build with FTS5. Without it,
+	// the index triggers would make every write fail, so drop them. The
+	// index is rebuilt when they're next created.
+	if !fts5Available(a.DB) {
+		for _, trigger := range searchIndexTriggers {
+			a.DB.Exec("DROP TRIGGER IF EXISTS " + trigger)
+		}
+		return
+	}
+
+	var triggers int
+	a.DB.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?)", searchIndexTriggers).Row().Scan(&triggers)
+	for _, sql := range searchIndexSQL {
+		if err := a.DB.Exec(sql).Error; err != nil {
+			return
+		}
+	}
+	a.searchIndex = true
+
+	// Index any stars written while the index was missing or out of sync.
+	if triggers != len(searchIndexTriggers) {
+		a.reindexSearch()
+	}
+}
+
+func (a *App) reindexSearch() error {
+	return a.DB.Exec("INSERT INTO stars_fts(stars_fts) VALUES ('rebuild')").Error
+}
+
+// searchRankOrder orders full-text search results from most to least
+// relevant, followed by substring-only matches.
+const searchRankOrder = "search.rank IS NULL, search.rank asc, stars.id asc"
+
+// searchWords matches the words in a search that FTS5 will index.
+var searchWords = regexp.MustCompile(`[\pL\pN_]+`)
+
+// ftsQuery turns a search into an FTS5 query matching every word as a
+// prefix, in any order, or returns "" if there are no words to match.
+func ftsQuery(q string) string {
+	words := searchWords.FindAllString(q, -1)
+	for index, word := range words {
+		words[index] = `"` + word + `"*`
+	}
+	return strings.Join(words, " ")
+}
+
+// likePattern builds a LIKE pattern matching s anywhere in a column, with
+// any wildcards in s escaped.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + strings.ToLower(s) + "%"
+}
+
+// searchFilter limits db to stars matching q by name or description. It
+// reports whether the results can be ordered by relevance, with
+// searchRankOrder.
+func (a *App) searchFilter(db *gorm.DB, q string) (*gorm.DB, bool) {
+	pattern := likePattern(q)
+	like := `LOWER(stars.name) LIKE ? ESCAPE '\' OR LOWER(stars.description) LIKE ? ESCAPE '\'`
+
+	if match := ftsQuery(q); a.searchIndex && match != "" {
+		// Words match anywhere through the index, and substrings still match
+		// with LIKE, so the index only ever adds results. Joining the index
+		// lets its rank order them.
+		return db.Select("stars.*").
+			Joins("LEFT JOIN (SELECT rowid, rank FROM stars_fts WHERE stars_fts MATCH ?) AS search ON search.rowid = stars.id", match).
+			Where("search.rowid IS NOT NULL OR "+like, pattern, pattern), true
+	}
+
+	return db.Where(like, pattern, pattern), false
+}
+
+// ReindexSearchHandler rebuilds the full-text search index from the stars
+// table, for when they have drifted apart.
+func (a *App) ReindexSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.searchIndex {
+		writeError(w, http.StatusNotImplemented, "search index not available")
+		return
+	}
+
+	// Rebuild the index, and count what's in it.
+	if err := a.reindexSearch(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	count := 0
+	if err := a.DB.Model(&Star{}).Count(&count).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+	reportJSON, _ := json.Marshal(map[string]int{"indexed": count})
+
+	// Write to HTTP response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(reportJSON)
+}
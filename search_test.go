@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func searchNames(t *testing.T, app *App, query string) []string {
+	req, err := http.NewRequest("GET", "/stars?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.ListHandler).ServeHTTP(rr, req)
+
+	returnedStars := []Star{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &returnedStars); err != nil {
+		t.Fatalf("Returned star list is invalid JSON. Got: %s", rr.Body.String())
+	}
+	names := []string{}
+	for _, star := range returnedStars {
+		names = append(names, star.Name)
+	}
+	return names
+}
+
+func reindexSearch(t *testing.T, app *App) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/admin/reindex-search", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.ReindexSearchHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestReindexSearchHandler(t *testing.T) {
+	app := setup()
+	if !app.searchIndex {
+		t.Skip("sqlite3 driver was not built with FTS5 support")
+	}
+
+	// Bulk insert stars directly, then throw away the index to simulate drift.
+	// Searching the words out of order means only the index can match.
+	for i := 0; i < 3; i++ {
+		app.DB.Exec("INSERT INTO stars (name, description, url) VALUES (?, ?, ?)",
+			fmt.Sprintf("test/bulk%d", i), "imported zeppelin", "test URL")
+	}
+	app.DB.Exec("INSERT INTO stars_fts(stars_fts) VALUES ('delete-all')")
+	if names := searchNames(t, app, "q=zeppelin+imported"); len(names) != 0 {
+		t.Fatalf("Search should miss stars dropped from the index. Got %v", names)
+	}
+
+	rr := reindexSearch(t, app)
+
+	// Test that the status code and count are correct.
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusOK, status)
+	}
+	expected := `{"indexed":3}`
+	if body := rr.Body.String(); body != expected {
+		t.Errorf("Reindex report is invalid. Expected %s. Got %s instead", expected, body)
+	}
+
+	// Test that the search finds the new rows.
+	if names := searchNames(t, app, "q=zeppelin+imported"); fmt.Sprint(names) != "[test/bulk0 test/bulk1 test/bulk2]" {
+		t.Errorf("Search results are invalid after reindex. Got %v", names)
+	}
+
+	teardown(app)
+}
+
+func TestReindexSearchHandlerUnavailable(t *testing.T) {
+	app := setup()
+	app.searchIndex = false
+
+	rr := reindexSearch(t, app)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusNotImplemented {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusNotImplemented, status)
+	}
+
+	// Test that search still works without the index.
+	app.DB.Create(&Star{Name: "test/name", Description: "test zeppelin"})
+	if names := searchNames(t, app, "q=zepp"); fmt.Sprint(names) != "[test/name]" {
+		t.Errorf("Search results are invalid without an index. Got %v", names)
+	}
+
+	teardown(app)
+}
+
+func TestSearchRanking(t *testing.T) {
+	app := setup()
+	if !app.searchIndex {
+		t.Skip("sqlite3 driver was not built with FTS5 support")
+	}
+
+	// Create stars that mention the search words to varying degrees.
+	stars := []Star{
+		Star{Name: "test/passing", Description: "a long list of tools, one of which does search, and some of which use sqlite as storage"},
+		Star{Name: "test/unrelated", Description: "nothing to see here"},
+		Star{Name: "test/sqlite-search", Description: "sqlite full-text search"},
+		Star{Name: "test/search-only", Description: "search engine"},
+	}
+	for _, star := range stars {
+		app.DB.Create(&Star{Name: star.Name, Description: star.Description})
+	}
+
+	// Set up a test table.
+	searchTests := []struct {
+		query string
+		names string
+	}{
+		// The most relevant match comes first, regardless of ID.
+		{query: "q=sqlite+search", names: "[test/sqlite-search test/passing]"},
+		// Words match anywhere, unlike LIKE which needs the exact phrase.
+		{query: "q=search+sqlite", names: "[test/sqlite-search test/passing]"},
+		// An explicit sort overrides relevance.
+		{query: "q=sqlite+search&sort=name", names: "[test/passing test/sqlite-search]"},
+	}
+
+	for _, tt := range searchTests {
+		if names := searchNames(t, app, tt.query); fmt.Sprint(names) != tt.names {
+			t.Errorf("Search results for %q are invalid. Expected %s. Got %v instead", tt.query, tt.names, names)
+		}
+	}
+
+	// Test that the LIKE fallback couldn't have found these at all.
+	app.searchIndex = false
+	if names := searchNames(t, app, "q=search+sqlite"); len(names) != 0 {
+		t.Errorf("LIKE search results are invalid. Expected none. Got %v instead", names)
+	}
+
+	teardown(app)
+}
+
+func TestSearchSubstring(t *testing.T) {
+	for _, searchIndex := range []bool{false, true} {
+		t.Run(fmt.Sprintf("index=%t", searchIndex), func(t *testing.T) {
+			app := setup()
+			defer teardown(app)
+			if searchIndex && !app.searchIndex {
+				t.Skip("sqlite3 driver was not built with FTS5 support")
+			}
+			app.searchIndex = searchIndex
+
+			app.DB.Create(&Star{Name: "rshipp/StarManager", Description: "golang bookmarks"})
+			app.DB.Create(&Star{Name: "test/other", Description: "unrelated"})
+
+			// Test that substrings match, with or without the index.
+			for _, q := range []string{"lang", "manager", "ship", "GOLANG", "p/star"} {
+				if names := searchNames(t, app, "q="+q); fmt.Sprint(names) != "[rshipp/StarManager]" {
+					t.Errorf("Search results for %q are invalid. Got %v", q, names)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchIndexWithoutFTS5(t *testing.T) {
+	app := setup()
+	teardown(app)
+	if !app.searchIndex {
+		t.Skip("sqlite3 driver was not built with FTS5 support")
+	}
+
+	dir, err := ioutil.TempDir("", "starmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stars.db")
+
+	// Index a database file, then reopen it as a build without FTS5 would.
+	app = &App{}
+	app.Initialize("sqlite3", path)
+	app.DB.Create(&Star{Name: "test/indexed", Description: "imported zeppelin"})
+	teardown(app)
+
+	available := fts5Available
+	fts5Available = func(*gorm.DB) bool { return false }
+	defer func() { fts5Available = available }()
+
+	app = &App{}
+	app.Initialize("sqlite3", path)
+	if app.searchIndex {
+		t.Errorf("Search index was used without FTS5")
+	}
+
+	// Test that stars can still be written, and found with LIKE.
+	if err := app.DB.Create(&Star{Name: "test/unindexed", Description: "imported zeppelin"}).Error; err != nil {
+		t.Fatalf("Failed to create a star without FTS5: %s", err)
+	}
+	if err := app.DB.Model(&Star{}).Where("name = ?", "test/indexed").Update("description", "zeppelin imported").Error; err != nil {
+		t.Fatalf("Failed to update a star without FTS5: %s", err)
+	}
+	if names := searchNames(t, app, "q=zeppelin"); fmt.Sprint(names) != "[test/indexed test/unindexed]" {
+		t.Errorf("Search results are invalid without FTS5. Got %v", names)
+	}
+	teardown(app)
+
+	// Test that the index catches up once FTS5 is back.
+	fts5Available = available
+	app = &App{}
+	app.Initialize("sqlite3", path)
+	defer teardown(app)
+	if !app.searchIndex {
+		t.Fatalf("Search index was not restored")
+	}
+	if names := searchNames(t, app, "q=zeppelin+imported&sort=name"); fmt.Sprint(names) != "[test/indexed test/unindexed]" {
+		t.Errorf("Search results are invalid after reopening with FTS5. Got %v", names)
+	}
+	if names := searchNames(t, app, "q=imported+zeppelin&sort=name"); fmt.Sprint(names) != "[test/indexed test/unindexed]" {
+		t.Errorf("Search results are invalid after reopening with FTS5. Got %v", names)
+	}
+}
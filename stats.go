@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// trafficStats counts requests seen by LoggingMiddleware since it was last read.
+type trafficStats struct {
+	mu           sync.Mutex
+	since        time.Time
+	requests     int
+	bytes        int
+	statuses     map[string]int
+	totalLatency time.Duration
+	rateLimited  int
+}
+
+// trafficReport is the JSON shape returned by TrafficStatsHandler.
+type trafficReport struct {
+	Since            time.Time      `json:"since"`
+	Requests         int            `json:"requests"`
+	Bytes            int            `json:"bytes"`
+	Statuses         map[string]int `json:"statuses"`
+	AverageLatencyMS float64        `json:"average_latency_ms"`
+	RateLimited      int            `json:"rate_limited"`
+}
+
+func (s *trafficStats) record(status int, bytes int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.statuses == nil {
+		s.statuses = map[string]int{}
+	}
+	if s.since.IsZero() {
+		s.since = time.Now().Add(-latency)
+	}
+	s.requests++
+	s.bytes += bytes
+	s.statuses[fmt.Sprintf("%dxx", status/100)]++
+	s.totalLatency += latency
+	if status == http.StatusTooManyRequests {
+		s.rateLimited++
+	}
+}
+
+// reset returns a report of the current counts and starts a new window.
+func (s *trafficStats) reset(now time.Time) trafficReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := trafficReport{
+		Since:       s.since,
+		Requests:    s.requests,
+		Bytes:       s.bytes,
+		Statuses:    s.statuses,
+		RateLimited: s.rateLimited,
+	}
+	if report.Statuses == nil {
+		report.Statuses = map[string]int{}
+	}
+	if s.requests > 0 {
+		report.AverageLatencyMS = float64(s.totalLatency) / float64(s.requests) / float64(time.Millisecond)
+	}
+
+	s.since = now
+	s.requests = 0
+	s.bytes = 0
+	s.statuses = nil
+	s.totalLatency = 0
+	s.rateLimited = 0
+	return report
+}
+
+// TrafficStatsHandler reports request counts by status class, response
+// bytes, average latency, and rate-limited (429) responses. The counters
+// are reset on each read, so every report covers the time since the
+// previous one.
+func (a *App) TrafficStatsHandler(w http.ResponseWriter, r *http.Request) {
+	reportJSON, _ := json.Marshal(a.traffic.reset(time.Now()))
+
+	// Write to HTTP response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(reportJSON)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrafficStatsHandler(t *testing.T) {
+	app := &App{}
+
+	// Set up a router that answers with the status in the path.
+	r := http.NewServeMux()
+	r.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	r.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(404) })
+	r.HandleFunc("/limited", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(429) })
+	handler := app.LoggingMiddleware(r)
+
+	// Make a few requests.
+	for _, path := range []string{"/ok", "/ok", "/missing", "/limited"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Set up a test table of consecutive reads and the counts they should report.
+	readTests := []struct {
+		requests    int
+		bytes       int
+		statuses    map[string]int
+		rateLimited int
+	}{
+		{requests: 4, bytes: 4, statuses: map[string]int{"2xx": 2, "4xx": 2}, rateLimited: 1},
+		{requests: 0, bytes: 0, statuses: map[string]int{}, rateLimited: 0},
+	}
+
+	for _, tt := range readTests {
+		req, err := http.NewRequest("GET", "/admin/stats/traffic", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(app.TrafficStatsHandler).ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusOK, status)
+		}
+
+		// Test that the counters reflect the requests.
+		report := trafficReport{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Report is invalid JSON. Got: %s", rr.Body.String())
+		}
+		if report.Requests != tt.requests || report.Bytes != tt.bytes || report.RateLimited != tt.rateLimited {
+			t.Errorf("Report is invalid. Expected %+v. Got %+v instead", tt, report)
+		}
+		if len(report.Statuses) != len(tt.statuses) {
+			t.Errorf("Status counts are invalid. Expected %v. Got %v instead", tt.statuses, report.Statuses)
+		}
+		for class, count := range tt.statuses {
+			if report.Statuses[class] != count {
+				t.Errorf("%s count is invalid. Expected %d. Got %d instead", class, count, report.Statuses[class])
+			}
+		}
+		if tt.requests == 0 && report.AverageLatencyMS != 0 {
+			t.Errorf("Average latency is invalid. Expected 0. Got %f instead", report.AverageLatencyMS)
+		}
+	}
+}
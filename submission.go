@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// submission is the metadata recorded about who created a star.
+type submission struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// adminStar is a star along with the fields only admins may see.
+type adminStar struct {
+	Star
+	Submission submission `json:"submission"`
+}
+
+// AdminViewHandler returns a star with its submission metadata.
+func (a *App) AdminViewHandler(w http.ResponseWriter, r *http.Request) {
+	var star Star
+	vars := mux.Vars(r)
+
+	// Select the star with the given name, and convert to JSON.
+	if err := a.DB.First(&star, "name = ?", vars["name"]).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+	starJSON, _ := json.Marshal(adminStar{
+		Star: star,
+		Submission: submission{
+			IP:        star.SubmitterIP,
+			UserAgent: star.SubmitterUserAgent,
+		},
+	})
+
+	// Write to HTTP response.
+	w.WriteHeader(200)
+	w.Write([]byte(starJSON))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRecordSubmitter(t *testing.T) {
+	app := setup()
+	app.Config.RecordSubmitter = true
+
+	// Create a star.
+	req, err := http.NewRequest("POST", "/stars", StarFormValues(Star{Name: "test/name", URL: "test url"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	http.HandlerFunc(app.CreateHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	// Test that the submission metadata was recorded.
+	expected := submission{IP: "192.0.2.1", UserAgent: "test-agent/1.0"}
+	createdStar := Star{}
+	app.DB.First(&createdStar)
+	if createdStar.SubmitterIP != expected.IP || createdStar.SubmitterUserAgent != expected.UserAgent {
+		t.Errorf("Submission metadata is invalid. Expected %+v. Got %+v instead", expected, createdStar)
+	}
+
+	// We need a mux router in order to pass in the `name` variable.
+	r := mux.NewRouter()
+
+	r.HandleFunc("/stars/{name:.*}", app.ViewHandler).Methods("GET")
+	r.HandleFunc("/admin/stars/{name:.*}", app.AdminViewHandler).Methods("GET")
+
+	// Set up a test table.
+	viewTests := []struct {
+		path    string
+		visible bool
+	}{
+		{path: "/stars/test/name", visible: false},
+		{path: "/admin/stars/test/name", visible: true},
+	}
+
+	for _, tt := range viewTests {
+		req, err := http.NewRequest("GET", tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// Test that the status code is correct.
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Status code for %s is invalid. Expected %d. Got %d instead", tt.path, http.StatusOK, status)
+		}
+
+		// Test that the metadata is only visible to admins.
+		returnedStar := map[string]interface{}{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &returnedStar); err != nil {
+			t.Fatalf("Returned star is invalid JSON. Got: %s", rr.Body.String())
+		}
+		if returnedStar["name"] != "test/name" {
+			t.Errorf("Returned star for %s is invalid. Got: %s", tt.path, rr.Body.String())
+		}
+		_, visible := returnedStar["submission"]
+		if visible != tt.visible || strings.Contains(rr.Body.String(), expected.IP) != tt.visible {
+			t.Errorf("Submission visibility for %s is invalid. Expected %t. Got: %s", tt.path, tt.visible, rr.Body.String())
+		}
+		if tt.visible {
+			returned := adminStar{}
+			json.Unmarshal(rr.Body.Bytes(), &returned)
+			if returned.Submission != expected {
+				t.Errorf("Returned submission is invalid. Expected %+v. Got %+v instead", expected, returned.Submission)
+			}
+		}
+	}
+
+	teardown(app)
+}
+
+func TestRecordSubmitterDisabled(t *testing.T) {
+	app := setup()
+
+	// Create a star with capture left off.
+	req, err := http.NewRequest("POST", "/stars", StarFormValues(Star{Name: "test/name", URL: "test url"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	http.HandlerFunc(app.CreateHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	// Test that nothing was recorded.
+	createdStar := Star{}
+	app.DB.First(&createdStar)
+	if createdStar.SubmitterIP != "" || createdStar.SubmitterUserAgent != "" {
+		t.Errorf("Submission metadata was recorded: %+v", createdStar)
+	}
+
+	teardown(app)
+}
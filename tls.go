@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps Config.TLSMinVersion values to crypto/tls versions.
+// Versions before 1.2 are deliberately left out as insecure.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the server's tls.Config from the TLS settings, or
+// returns an error if they are invalid or insecure.
+func (c Config) TLSConfig() (*tls.Config, error) {
+	minVersion := c.TLSMinVersion
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported or insecure minimum TLS version: %s", minVersion)
+	}
+	config := &tls.Config{MinVersion: version}
+
+	// Look up the configured cipher suites by name, allowing only secure ones.
+	// Go doesn't allow configuring TLS 1.3 suites, so these only affect TLS 1.2.
+	if len(c.TLSCipherSuites) > 0 && version != tls.VersionTLS12 {
+		return nil, fmt.Errorf("TLS cipher suites can't be configured with minimum TLS version %s", minVersion)
+	}
+	for _, name := range c.TLSCipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown, insecure, or non-TLS 1.2 cipher suite: %s", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+
+	return config, nil
+}
+
+// cipherSuiteID looks up a secure TLS 1.2 cipher suite by name.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				return suite.ID, true
+			}
+		}
+	}
+	return 0, false
+}
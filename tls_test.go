@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTLSConfig(t *testing.T) {
+	// Set up a test table.
+	configTests := []struct {
+		config       Config
+		minVersion   uint16
+		cipherSuites []uint16
+	}{
+		{config: Config{}, minVersion: tls.VersionTLS12},
+		{config: Config{TLSMinVersion: "1.3"}, minVersion: tls.VersionTLS13},
+		{config: Config{TLSMinVersion: "1.2", TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}},
+			minVersion:   tls.VersionTLS12,
+			cipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		},
+	}
+
+	for _, tt := range configTests {
+		tlsConfig, err := tt.config.TLSConfig()
+		if err != nil {
+			t.Fatalf("Valid config %+v was rejected: %s", tt.config, err)
+		}
+
+		// Test that the tls.Config reflects the settings.
+		if tlsConfig.MinVersion != tt.minVersion {
+			t.Errorf("MinVersion is invalid. Expected %x. Got %x instead", tt.minVersion, tlsConfig.MinVersion)
+		}
+		if len(tlsConfig.CipherSuites) != len(tt.cipherSuites) {
+			t.Fatalf("CipherSuites is invalid. Expected %v. Got %v instead", tt.cipherSuites, tlsConfig.CipherSuites)
+		}
+		for index, id := range tt.cipherSuites {
+			if tlsConfig.CipherSuites[index] != id {
+				t.Errorf("CipherSuites is invalid. Expected %v. Got %v instead", tt.cipherSuites, tlsConfig.CipherSuites)
+			}
+		}
+	}
+}
+
+func TestTLSConfigInsecure(t *testing.T) {
+	// Set up a test table of configs that should be rejected.
+	configTests := []Config{
+		Config{TLSMinVersion: "1.0"},
+		Config{TLSMinVersion: "1.1"},
+		Config{TLSMinVersion: "banana"},
+		Config{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		Config{TLSCipherSuites: []string{"TLS_NOT_A_REAL_SUITE"}},
+		// TLS 1.3 suites would be ignored, leaving TLS 1.2 with no suites.
+		Config{TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+		// Suites can't be configured for TLS 1.3.
+		Config{TLSMinVersion: "1.3", TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+	}
+
+	for _, config := range configTests {
+		if _, err := config.TLSConfig(); err == nil {
+			t.Errorf("Insecure config %+v was accepted", config)
+		}
+	}
+}
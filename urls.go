@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// validateURL checks that s is an absolute http or https URL with a host.
+func validateURL(s string) error {
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return errors.New("not a valid absolute URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+// invalidURL is an entry in the report returned by ValidateURLsHandler.
+type invalidURL struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// ValidateURLsHandler reports every star whose URL fails validateURL. It
+// doesn't modify anything.
+func (a *App) ValidateURLsHandler(w http.ResponseWriter, r *http.Request) {
+	var stars []Star
+	invalid := []invalidURL{}
+
+	// Select just the names and URLs of all stars, and check each URL.
+	if err := a.DB.Select("name, url").Order("name asc").Find(&stars).Error; err != nil {
+		writeDBError(w, err)
+		return
+	}
+	for _, star := range stars {
+		if err := validateURL(star.URL); err != nil {
+			invalid = append(invalid, invalidURL{Name: star.Name, URL: star.URL, Error: err.Error()})
+		}
+	}
+	reportJSON, _ := json.Marshal(map[string]interface{}{
+		"checked": len(stars),
+		"invalid": invalid,
+	})
+
+	// Write to HTTP response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(reportJSON)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateURLsHandler(t *testing.T) {
+	app := setup()
+
+	// Create a mix of stars with valid and invalid URLs.
+	stars := []Star{
+		Star{ID: 1, Name: "test/valid", URL: "https://github.com/test/valid"},
+		Star{ID: 2, Name: "test/no_scheme", URL: "github.com/test/no_scheme"},
+		Star{ID: 3, Name: "test/valid_http", URL: "http://example.com/"},
+		Star{ID: 4, Name: "test/ftp", URL: "ftp://example.com/file"},
+		Star{ID: 5, Name: "test/empty", URL: ""},
+	}
+	for _, star := range stars {
+		app.DB.Create(star)
+	}
+
+	// Set up a new request.
+	req, err := http.NewRequest("GET", "/admin/validate-urls", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(app.ValidateURLsHandler).ServeHTTP(rr, req)
+
+	// Test that the status code is correct.
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Status code is invalid. Expected %d. Got %d instead", http.StatusOK, status)
+	}
+
+	// Test that only the invalid URLs are reported.
+	report := struct {
+		Checked int          `json:"checked"`
+		Invalid []invalidURL `json:"invalid"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Report is invalid JSON. Got: %s", rr.Body.String())
+	}
+	if report.Checked != len(stars) {
+		t.Errorf("Checked count is invalid. Expected %d. Got %d instead", len(stars), report.Checked)
+	}
+	expected := []string{"test/empty", "test/ftp", "test/no_scheme"}
+	if len(report.Invalid) != len(expected) {
+		t.Fatalf("Report is invalid. Expected %v. Got %+v instead", expected, report.Invalid)
+	}
+	for index, entry := range report.Invalid {
+		if entry.Name != expected[index] || entry.Error == "" {
+			t.Errorf("Report entry is invalid. Expected %s. Got %+v instead", expected[index], entry)
+		}
+	}
+
+	// Test that nothing was modified.
+	for _, star := range stars {
+		storedStar := Star{}
+		app.DB.First(&storedStar, star.ID)
+		if storedStar != star {
+			t.Errorf("Star was modified. Expected %+v. Got %+v instead", star, storedStar)
+		}
+	}
+
+	teardown(app)
+}